@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// adminBackend is the JSON representation of a single backend's state
+// returned by GET /admin/backends.
+type adminBackend struct {
+	URL          string  `json:"url"`
+	Alive        bool    `json:"alive"`
+	Drained      bool    `json:"drained"`
+	BreakerState string  `json:"breaker_state"`
+	Weight       int     `json:"weight"`
+	ActiveConns  int64   `json:"active_conns"`
+	EWMA         float64 `json:"ewma"`
+}
+
+// NewAdminHandler builds the admin mux served on the -config's
+// admin_addr: Prometheus metrics, a JSON dump of pool state, and
+// drain/undrain endpoints for gracefully pulling a backend out ahead of
+// a deploy.
+func NewAdminHandler(router *Router) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.HandleFunc("/admin/backends", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeBackendsJSON(w, router)
+	})
+	mux.HandleFunc("/admin/backends/", func(w http.ResponseWriter, r *http.Request) {
+		handleDrain(w, r, router)
+	})
+	return mux
+}
+
+// writeBackendsJSON writes the state of every backend, across every
+// pool, as a JSON array.
+func writeBackendsJSON(w http.ResponseWriter, router *Router) {
+	out := []adminBackend{}
+	for _, pool := range router.Pools() {
+		for _, b := range pool.backends {
+			out = append(out, adminBackend{
+				URL:          b.URL.String(),
+				Alive:        b.IsAlive(),
+				Drained:      b.IsDrained(),
+				BreakerState: b.BreakerState(),
+				Weight:       b.Weight,
+				ActiveConns:  atomic.LoadInt64(&b.ActiveConns),
+				EWMA:         b.EWMA(),
+			})
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
+
+// handleDrain handles POST /admin/backends/{url}/drain and
+// POST /admin/backends/{url}/undrain, sticky-marking the matching
+// backend down (or clearing that mark) everywhere it appears in the
+// router. Unlike MarkBackendStatus, a drain survives health checks
+// until it's explicitly undrained, so it actually holds a backend out
+// of rotation for the length of a deploy.
+func handleDrain(w http.ResponseWriter, r *http.Request, router *Router) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	var drained bool
+	var encoded string
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/drain"):
+		drained = true
+		encoded = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/backends/"), "/drain")
+	case strings.HasSuffix(r.URL.Path, "/undrain"):
+		drained = false
+		encoded = strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/admin/backends/"), "/undrain")
+	default:
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+
+	target, err := url.QueryUnescape(encoded)
+	if err != nil {
+		http.Error(w, "invalid backend URL", http.StatusBadRequest)
+		return
+	}
+
+	found := false
+	for _, pool := range router.Pools() {
+		for _, b := range pool.backends {
+			if b.URL.String() == target {
+				b.SetDrained(drained)
+				found = true
+			}
+		}
+	}
+	if !found {
+		http.Error(w, "backend not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
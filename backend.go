@@ -0,0 +1,166 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ewmaDecay controls how much a new latency sample moves a Backend's
+// running average response time; lower values weight history more heavily.
+const ewmaDecay = 0.3
+
+// Backend holds the data about a server
+type Backend struct {
+	URL              *url.URL
+	Alive            bool
+	Weight           int
+	ActiveConns      int64
+	Retries          int
+	Delay            time.Duration
+	HealthCheckPath  string
+	FailureThreshold int
+	SuccessThreshold int
+	ReverseProxy     *httputil.ReverseProxy
+	// Transport is the *http.Transport used to reach this backend,
+	// carrying its TLS settings (e.g. InsecureSkipVerify); the health
+	// checker reuses it so probes of self-signed HTTPS backends behave
+	// the same way proxied requests do.
+	Transport *http.Transport
+
+	breaker *circuitBreaker
+
+	mux           sync.RWMutex
+	ewma          float64
+	consecFails   int
+	consecSuccess int
+	drained       bool
+}
+
+// SetAlive for this backend
+func (b *Backend) SetAlive(alive bool) {
+	b.mux.Lock()
+	b.Alive = alive
+	b.mux.Unlock()
+}
+
+// IsAlive returns true when backend is alive
+func (b *Backend) IsAlive() (alive bool) {
+	b.mux.RLock()
+	alive = b.Alive
+	b.mux.RUnlock()
+	return
+}
+
+// SetDrained sticky-marks this backend down for a graceful deploy
+// drain: unlike SetAlive, health checks cannot bring it back until
+// Undrain (SetDrained(false)) clears the flag. Draining also marks the
+// backend not alive so it drops out of rotation immediately.
+func (b *Backend) SetDrained(drained bool) {
+	b.mux.Lock()
+	b.drained = drained
+	if drained {
+		b.Alive = false
+	}
+	b.mux.Unlock()
+}
+
+// IsDrained returns true when this backend has been sticky-marked down
+// for a deploy drain.
+func (b *Backend) IsDrained() (drained bool) {
+	b.mux.RLock()
+	drained = b.drained
+	b.mux.RUnlock()
+	return
+}
+
+// Available reports whether the balancer should consider this backend:
+// it must be marked alive by health checks and its circuit breaker must
+// not be tripped open.
+func (b *Backend) Available() bool {
+	return b.IsAlive() && b.breaker.CanServe()
+}
+
+// BreakerState returns the name of this backend's current circuit
+// breaker state, for the admin/metrics endpoints.
+func (b *Backend) BreakerState() string {
+	return b.breaker.State().String()
+}
+
+// RecordCheck folds the result of a single liveness probe into the
+// backend's consecutive failure/success counts, flipping Alive only
+// once FailureThreshold or SuccessThreshold consecutive probes agree.
+// This keeps a single flaky probe from flapping the backend in and out
+// of the pool. It returns the backend's Alive state after the update.
+func (b *Backend) RecordCheck(alive bool) bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if alive {
+		b.consecSuccess++
+		b.consecFails = 0
+		if !b.Alive && !b.drained && b.consecSuccess >= threshold(b.SuccessThreshold) {
+			b.Alive = true
+		}
+	} else {
+		b.consecFails++
+		b.consecSuccess = 0
+		if b.Alive && b.consecFails >= threshold(b.FailureThreshold) {
+			b.Alive = false
+		}
+	}
+	return b.Alive
+}
+
+// threshold defaults an unset (zero) consecutive-check threshold to 1,
+// so a backend without explicit config flips on the very first probe.
+func threshold(n int) int {
+	if n <= 0 {
+		return 1
+	}
+	return n
+}
+
+// backoffSchedule returns this backend's retry backoff schedule, using
+// its configured Delay as the initial interval when set.
+func (b *Backend) backoffSchedule() Backoff {
+	bo := defaultBackoff
+	if b.Delay > 0 {
+		bo.Initial = b.Delay
+	}
+	return bo
+}
+
+// EWMA returns the backend's exponentially-weighted moving average
+// response time, in seconds.
+func (b *Backend) EWMA() float64 {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return b.ewma
+}
+
+// recordLatency folds a new response time into the backend's EWMA.
+func (b *Backend) recordLatency(d time.Duration) {
+	seconds := d.Seconds()
+	b.mux.Lock()
+	if b.ewma == 0 {
+		b.ewma = seconds
+	} else {
+		b.ewma = ewmaDecay*seconds + (1-ewmaDecay)*b.ewma
+	}
+	b.mux.Unlock()
+}
+
+// Serve proxies r to this backend, tracking in-flight connections and
+// response latency for the least-connections and EWMA balancers.
+func (b *Backend) Serve(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt64(&b.ActiveConns, 1)
+	defer atomic.AddInt64(&b.ActiveConns, -1)
+
+	start := time.Now()
+	b.ReverseProxy.ServeHTTP(w, r)
+	b.recordLatency(time.Since(start))
+}
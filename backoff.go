@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Backoff describes an exponential backoff schedule with jitter, used
+// to space out retries against the same backend so failed requests
+// don't all retry in lockstep at the same fixed interval.
+type Backoff struct {
+	Initial        time.Duration
+	Multiplier     float64
+	Max            time.Duration
+	Jitter         float64
+	MaxElapsedTime time.Duration
+}
+
+// defaultBackoff is the schedule used when a backend doesn't override
+// Initial via its configured Delay: 50ms growing by 1.5x per retry,
+// capped at 2s, with ±30% jitter, giving up after 10s total wait.
+var defaultBackoff = Backoff{
+	Initial:        50 * time.Millisecond,
+	Multiplier:     1.5,
+	Max:            2 * time.Second,
+	Jitter:         0.3,
+	MaxElapsedTime: 10 * time.Second,
+}
+
+// backoffState carries the running delay and total elapsed wait time
+// across retries against a single backend.
+type backoffState struct {
+	delay   time.Duration
+	elapsed time.Duration
+}
+
+// BackoffKey is the context key backoffState is stored under.
+const BackoffKey contextKey = "BackoffKey"
+
+// SetBackoffState is ...
+func SetBackoffState(parent context.Context, state backoffState) context.Context {
+	return context.WithValue(parent, BackoffKey, state)
+}
+
+// GetBackoffState is ...
+func GetBackoffState(ctx context.Context) backoffState {
+	state, _ := ctx.Value(BackoffKey).(backoffState)
+	return state
+}
+
+// Next computes the jittered delay to sleep before the next retry, the
+// resulting state to carry forward, and whether MaxElapsedTime has
+// been exceeded and no further retry should be attempted.
+func (b Backoff) Next(prev backoffState) (sleep time.Duration, state backoffState, exceeded bool) {
+	base := prev.delay
+	if base <= 0 {
+		base = b.Initial
+	} else {
+		base = time.Duration(float64(base) * b.Multiplier)
+	}
+	if b.Max > 0 && base > b.Max {
+		base = b.Max
+	}
+
+	sleep = applyJitter(base, b.Jitter)
+	state = backoffState{delay: base, elapsed: prev.elapsed + sleep}
+	exceeded = b.MaxElapsedTime > 0 && state.elapsed > b.MaxElapsedTime
+	return sleep, state, exceeded
+}
+
+// applyJitter randomizes d by up to ±fraction, so many clients
+// retrying the same backend at once don't all wake up together.
+func applyJitter(d time.Duration, fraction float64) time.Duration {
+	if fraction <= 0 {
+		return d
+	}
+	delta := (rand.Float64()*2 - 1) * fraction
+	jittered := float64(d) * (1 + delta)
+	if jittered < 0 {
+		jittered = 0
+	}
+	return time.Duration(jittered)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoffNextGrowsAndCaps(t *testing.T) {
+	b := Backoff{
+		Initial:        10 * time.Millisecond,
+		Multiplier:     2,
+		Max:            30 * time.Millisecond,
+		Jitter:         0, // deterministic: no jitter
+		MaxElapsedTime: 0, // never exceeded
+	}
+
+	sleep, state, exceeded := b.Next(backoffState{})
+	if exceeded {
+		t.Fatal("first retry should not exceed MaxElapsedTime")
+	}
+	if sleep != 10*time.Millisecond {
+		t.Fatalf("expected first sleep of 10ms, got %s", sleep)
+	}
+
+	sleep, state, exceeded = b.Next(state)
+	if exceeded {
+		t.Fatal("second retry should not exceed MaxElapsedTime")
+	}
+	if sleep != 20*time.Millisecond {
+		t.Fatalf("expected second sleep of 20ms, got %s", sleep)
+	}
+
+	// third retry would grow to 40ms, but Max caps it at 30ms
+	sleep, _, exceeded = b.Next(state)
+	if exceeded {
+		t.Fatal("third retry should not exceed MaxElapsedTime")
+	}
+	if sleep != 30*time.Millisecond {
+		t.Fatalf("expected third sleep to be capped at 30ms, got %s", sleep)
+	}
+}
+
+func TestBackoffNextExceedsMaxElapsedTime(t *testing.T) {
+	b := Backoff{
+		Initial:        10 * time.Millisecond,
+		Multiplier:     2,
+		Max:            1 * time.Second,
+		Jitter:         0,
+		MaxElapsedTime: 15 * time.Millisecond,
+	}
+
+	_, state, exceeded := b.Next(backoffState{})
+	if exceeded {
+		t.Fatal("first 10ms retry should not yet exceed a 15ms budget")
+	}
+
+	_, _, exceeded = b.Next(state)
+	if !exceeded {
+		t.Fatal("expected MaxElapsedTime to be exceeded once cumulative elapsed time passes it")
+	}
+}
+
+func TestApplyJitterStaysWithinBounds(t *testing.T) {
+	d := 100 * time.Millisecond
+	for i := 0; i < 100; i++ {
+		got := applyJitter(d, 0.3)
+		if got < 70*time.Millisecond || got > 130*time.Millisecond {
+			t.Fatalf("jittered duration %s outside ±30%% of %s", got, d)
+		}
+	}
+}
+
+func TestApplyJitterZeroFraction(t *testing.T) {
+	d := 50 * time.Millisecond
+	if got := applyJitter(d, 0); got != d {
+		t.Fatalf("expected zero jitter to return d unchanged, got %s", got)
+	}
+}
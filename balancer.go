@@ -0,0 +1,180 @@
+package main
+
+import (
+	"hash/fnv"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Balancer selects the backend that should handle a request from a pool.
+type Balancer interface {
+	// Next returns the backend that should serve r, or nil if none of
+	// the given backends are alive.
+	Next(backends []*Backend, r *http.Request) *Backend
+}
+
+// NewBalancer builds the Balancer for the given -strategy flag value.
+// Unrecognised strategies fall back to round robin.
+func NewBalancer(strategy string) Balancer {
+	switch strategy {
+	case "weighted-round-robin":
+		return &WeightedRoundRobinBalancer{}
+	case "least-connections":
+		return &LeastConnectionsBalancer{}
+	case "ip-hash":
+		return &IPHashBalancer{}
+	case "ewma":
+		return &EWMABalancer{}
+	default:
+		return &RoundRobinBalancer{}
+	}
+}
+
+// RoundRobinBalancer cycles through backends in order, skipping any that
+// are marked dead.
+type RoundRobinBalancer struct {
+	current uint64
+}
+
+// Next implements Balancer.
+func (b *RoundRobinBalancer) Next(backends []*Backend, r *http.Request) *Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	next := int(atomic.AddUint64(&b.current, 1) % uint64(len(backends)))
+	l := len(backends) + next
+	for i := next; i < l; i++ {
+		idx := i % len(backends)
+		if backends[idx].Available() {
+			if i != next {
+				atomic.StoreUint64(&b.current, uint64(idx))
+			}
+			return backends[idx]
+		}
+	}
+	return nil
+}
+
+// WeightedRoundRobinBalancer implements smooth weighted round robin:
+// each pick favors the backend whose accumulated weight is highest,
+// then discounts it by the total weight so heavier backends still get
+// picked more often without bursts of consecutive requests.
+type WeightedRoundRobinBalancer struct {
+	mu      sync.Mutex
+	current map[*Backend]int
+}
+
+// Next implements Balancer.
+func (b *WeightedRoundRobinBalancer) Next(backends []*Backend, r *http.Request) *Backend {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.current == nil {
+		b.current = make(map[*Backend]int)
+	}
+
+	var best *Backend
+	total := 0
+	for _, backend := range backends {
+		if !backend.Available() {
+			continue
+		}
+		weight := backend.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		total += weight
+		b.current[backend] += weight
+		if best == nil || b.current[backend] > b.current[best] {
+			best = backend
+		}
+	}
+	if best != nil {
+		b.current[best] -= total
+	}
+	return best
+}
+
+// LeastConnectionsBalancer sends each request to the alive backend with
+// the fewest in-flight requests.
+type LeastConnectionsBalancer struct{}
+
+// Next implements Balancer.
+func (b *LeastConnectionsBalancer) Next(backends []*Backend, r *http.Request) *Backend {
+	var best *Backend
+	var bestConns int64
+	for _, backend := range backends {
+		if !backend.Available() {
+			continue
+		}
+		conns := atomic.LoadInt64(&backend.ActiveConns)
+		if best == nil || conns < bestConns {
+			best = backend
+			bestConns = conns
+		}
+	}
+	return best
+}
+
+// IPHashBalancer hashes the client IP over the alive backends so that a
+// given client keeps hitting the same backend, giving it session
+// affinity.
+type IPHashBalancer struct{}
+
+// Next implements Balancer.
+func (b *IPHashBalancer) Next(backends []*Backend, r *http.Request) *Backend {
+	alive := make([]*Backend, 0, len(backends))
+	for _, backend := range backends {
+		if backend.Available() {
+			alive = append(alive, backend)
+		}
+	}
+	if len(alive) == 0 {
+		return nil
+	}
+
+	// Sorting gives a stable ordering to hash against regardless of the
+	// order backends were added or iterated in.
+	sort.Slice(alive, func(i, j int) bool {
+		return alive[i].URL.String() < alive[j].URL.String()
+	})
+
+	h := fnv.New32a()
+	h.Write([]byte(clientIP(r)))
+	return alive[int(h.Sum32())%len(alive)]
+}
+
+// clientIP extracts the client address from a request, stripping the
+// port if present.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// EWMABalancer picks the alive backend with the lowest exponentially
+// weighted moving average response time, favoring backends that have
+// been responding quickly.
+type EWMABalancer struct{}
+
+// Next implements Balancer.
+func (b *EWMABalancer) Next(backends []*Backend, r *http.Request) *Backend {
+	var best *Backend
+	var bestEWMA float64
+	for _, backend := range backends {
+		if !backend.Available() {
+			continue
+		}
+		ewma := backend.EWMA()
+		if best == nil || ewma < bestEWMA {
+			best = backend
+			bestEWMA = ewma
+		}
+	}
+	return best
+}
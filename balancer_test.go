@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// newTestBackend builds a minimal *Backend suitable for exercising a
+// Balancer: alive, with a closed circuit breaker so Available() only
+// reflects the alive flag.
+func newTestBackend(t *testing.T, rawURL string, alive bool, weight int) *Backend {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("url.Parse(%q): %v", rawURL, err)
+	}
+	return &Backend{
+		URL:     u,
+		Alive:   alive,
+		Weight:  weight,
+		breaker: newCircuitBreaker(rawURL, 0, 0, 0),
+	}
+}
+
+func TestRoundRobinBalancerSkipsDeadBackends(t *testing.T) {
+	dead := newTestBackend(t, "http://dead", false, 1)
+	a := newTestBackend(t, "http://a", true, 1)
+	b := newTestBackend(t, "http://b", true, 1)
+	backends := []*Backend{dead, a, b}
+
+	balancer := &RoundRobinBalancer{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	seen := make(map[*Backend]bool)
+	for i := 0; i < 10; i++ {
+		picked := balancer.Next(backends, req)
+		if picked == nil {
+			t.Fatalf("Next returned nil on iteration %d", i)
+		}
+		if picked == dead {
+			t.Fatalf("Next picked the dead backend on iteration %d", i)
+		}
+		seen[picked] = true
+	}
+	if !seen[a] || !seen[b] {
+		t.Fatalf("expected round robin to cycle through both alive backends, got %v", seen)
+	}
+}
+
+func TestRoundRobinBalancerNoneAlive(t *testing.T) {
+	backends := []*Backend{newTestBackend(t, "http://a", false, 1)}
+	balancer := &RoundRobinBalancer{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if picked := balancer.Next(backends, req); picked != nil {
+		t.Fatalf("expected nil when no backend is alive, got %v", picked)
+	}
+}
+
+func TestRoundRobinBalancerEmptyBackends(t *testing.T) {
+	balancer := &RoundRobinBalancer{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if picked := balancer.Next(nil, req); picked != nil {
+		t.Fatalf("expected nil for an empty backend list, got %v", picked)
+	}
+}
+
+func TestWeightedRoundRobinBalancerFavorsHigherWeight(t *testing.T) {
+	heavy := newTestBackend(t, "http://heavy", true, 3)
+	light := newTestBackend(t, "http://light", true, 1)
+	backends := []*Backend{heavy, light}
+
+	balancer := &WeightedRoundRobinBalancer{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	counts := map[*Backend]int{}
+	const rounds = 40
+	for i := 0; i < rounds; i++ {
+		counts[balancer.Next(backends, req)]++
+	}
+
+	if counts[heavy] <= counts[light] {
+		t.Fatalf("expected heavy (weight 3) to be picked more often than light (weight 1), got heavy=%d light=%d", counts[heavy], counts[light])
+	}
+	// over a multiple of 4 rounds, smooth WRR should land close to the 3:1 ratio
+	want := rounds * 3 / 4
+	if counts[heavy] != want {
+		t.Fatalf("expected heavy to be picked %d/%d times, got %d", want, rounds, counts[heavy])
+	}
+}
+
+func TestIPHashBalancerIsConsistentAndSkipsDead(t *testing.T) {
+	dead := newTestBackend(t, "http://dead", false, 1)
+	a := newTestBackend(t, "http://a", true, 1)
+	b := newTestBackend(t, "http://b", true, 1)
+	backends := []*Backend{dead, a, b}
+
+	balancer := &IPHashBalancer{}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:54321"
+
+	first := balancer.Next(backends, req)
+	if first == nil {
+		t.Fatal("expected a backend, got nil")
+	}
+	if first == dead {
+		t.Fatal("IPHashBalancer picked the dead backend")
+	}
+	for i := 0; i < 5; i++ {
+		if got := balancer.Next(backends, req); got != first {
+			t.Fatalf("expected the same backend for the same client IP, got %v want %v", got, first)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// breakerState is a circuit breaker's position in the standard
+// closed -> open -> half-open -> closed cycle.
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// String implements fmt.Stringer, for the admin/metrics endpoints.
+func (s breakerState) String() string {
+	switch s {
+	case breakerOpen:
+		return "open"
+	case breakerHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+// Defaults used when a backend doesn't configure its own circuit
+// breaker behavior.
+const (
+	defaultCircuitThreshold   = 5
+	defaultCircuitCooldown    = 30 * time.Second
+	defaultCircuitHalfOpenMax = 1
+)
+
+// errCircuitOpen is the error metricsTransport returns in place of an
+// actual round trip when a backend's circuit breaker has no half-open
+// probe slots available.
+var errCircuitOpen = errors.New("circuit breaker open: backend is known-bad")
+
+// circuitBreaker fails requests to a backend fast once it has built up
+// threshold consecutive failures, instead of burning the full retry
+// budget on a backend that's known-bad. Once tripped it stays open for
+// cooldown, then allows up to halfOpenMax probe requests through to
+// decide whether to close again.
+type circuitBreaker struct {
+	backendURL  string
+	threshold   int
+	cooldown    time.Duration
+	halfOpenMax int
+
+	mu             sync.Mutex
+	state          breakerState
+	fails          int
+	openedAt       time.Time
+	halfOpenProbes int
+}
+
+// newCircuitBreaker builds a closed circuitBreaker for backendURL,
+// defaulting any unset tuning to a sane value.
+func newCircuitBreaker(backendURL string, threshold int, cooldown time.Duration, halfOpenMax int) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = defaultCircuitThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = defaultCircuitCooldown
+	}
+	if halfOpenMax <= 0 {
+		halfOpenMax = defaultCircuitHalfOpenMax
+	}
+	return &circuitBreaker{
+		backendURL:  backendURL,
+		threshold:   threshold,
+		cooldown:    cooldown,
+		halfOpenMax: halfOpenMax,
+	}
+}
+
+// CanServe reports whether the balancer should still consider this
+// backend, without reserving a probe slot: closed is always available,
+// half-open is available while probe slots remain, and open is not
+// available until its cooldown has elapsed.
+func (c *circuitBreaker) CanServe() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	switch c.state {
+	case breakerOpen:
+		return time.Since(c.openedAt) >= c.cooldown
+	case breakerHalfOpen:
+		return c.halfOpenProbes < c.halfOpenMax
+	default:
+		return true
+	}
+}
+
+// Allow reserves a slot for an actual attempt against the backend,
+// transitioning an open breaker to half-open once its cooldown has
+// elapsed. It returns false when the circuit is open, or every
+// half-open probe slot has already been issued.
+func (c *circuitBreaker) Allow() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == breakerOpen && time.Since(c.openedAt) >= c.cooldown {
+		c.halfOpenProbes = 0
+		c.setState(breakerHalfOpen)
+	}
+
+	switch c.state {
+	case breakerOpen:
+		return false
+	case breakerHalfOpen:
+		if c.halfOpenProbes >= c.halfOpenMax {
+			return false
+		}
+		c.halfOpenProbes++
+		return true
+	default:
+		return true
+	}
+}
+
+// RecordSuccess resets the consecutive-failure count, closing the
+// breaker if the success was a half-open probe.
+func (c *circuitBreaker) RecordSuccess() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.fails = 0
+	if c.state == breakerHalfOpen {
+		c.setState(breakerClosed)
+	}
+}
+
+// Trip folds a failed request, whose retry budget against this backend
+// has been exhausted, into the breaker's consecutive-failure count,
+// opening the circuit once threshold is reached. A failed half-open
+// probe re-opens the circuit immediately.
+func (c *circuitBreaker) Trip() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.state == breakerHalfOpen {
+		c.openedAt = time.Now()
+		c.setState(breakerOpen)
+		return
+	}
+
+	c.fails++
+	if c.fails >= c.threshold {
+		c.openedAt = time.Now()
+		c.setState(breakerOpen)
+	}
+}
+
+// State returns the breaker's current state, for the admin/metrics
+// endpoints.
+func (c *circuitBreaker) State() breakerState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state
+}
+
+// setState updates the breaker's state and the lb_circuit_breaker_state
+// gauge together. Callers must hold c.mu.
+func (c *circuitBreaker) setState(s breakerState) {
+	c.state = s
+	circuitBreakerState.WithLabelValues(c.backendURL).Set(float64(s))
+}
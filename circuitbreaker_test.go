@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTripsOpenAfterThreshold(t *testing.T) {
+	cb := newCircuitBreaker("http://backend", 3, time.Minute, 1)
+
+	for i := 0; i < 2; i++ {
+		cb.Trip()
+		if got := cb.State(); got != breakerClosed {
+			t.Fatalf("expected closed after %d failure(s), got %s", i+1, got)
+		}
+		if !cb.CanServe() {
+			t.Fatal("expected breaker to still allow traffic before threshold is reached")
+		}
+	}
+
+	cb.Trip()
+	if got := cb.State(); got != breakerOpen {
+		t.Fatalf("expected open after threshold consecutive failures, got %s", got)
+	}
+	if cb.CanServe() {
+		t.Fatal("expected an open breaker within its cooldown to refuse traffic")
+	}
+	if cb.Allow() {
+		t.Fatal("expected Allow to refuse a request while the breaker is open")
+	}
+}
+
+func TestCircuitBreakerSuccessResetsFailureCount(t *testing.T) {
+	cb := newCircuitBreaker("http://backend", 3, time.Minute, 1)
+
+	cb.Trip()
+	cb.Trip()
+	cb.RecordSuccess()
+	cb.Trip()
+	cb.Trip()
+
+	if got := cb.State(); got != breakerClosed {
+		t.Fatalf("expected breaker to stay closed since no 3 failures were ever consecutive, got %s", got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenClosesOnSuccess(t *testing.T) {
+	cb := newCircuitBreaker("http://backend", 1, time.Millisecond, 1)
+
+	cb.Trip()
+	if got := cb.State(); got != breakerOpen {
+		t.Fatalf("expected open after a single failure at threshold 1, got %s", got)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if !cb.CanServe() {
+		t.Fatal("expected CanServe to allow a probe once cooldown has elapsed")
+	}
+	if !cb.Allow() {
+		t.Fatal("expected Allow to grant the first half-open probe slot")
+	}
+	if got := cb.State(); got != breakerHalfOpen {
+		t.Fatalf("expected Allow to transition open -> half-open, got %s", got)
+	}
+	if cb.Allow() {
+		t.Fatal("expected Allow to refuse a second probe once halfOpenMax is exhausted")
+	}
+
+	cb.RecordSuccess()
+	if got := cb.State(); got != breakerClosed {
+		t.Fatalf("expected a successful half-open probe to close the breaker, got %s", got)
+	}
+}
+
+func TestCircuitBreakerHalfOpenReopensOnFailure(t *testing.T) {
+	cb := newCircuitBreaker("http://backend", 1, time.Millisecond, 1)
+
+	cb.Trip() // closed -> open
+	time.Sleep(5 * time.Millisecond)
+	if !cb.Allow() { // open -> half-open, consumes the one probe slot
+		t.Fatal("expected the half-open probe to be allowed")
+	}
+
+	cb.Trip() // failed probe should re-open immediately
+	if got := cb.State(); got != breakerOpen {
+		t.Fatalf("expected a failed half-open probe to re-open the breaker, got %s", got)
+	}
+	if cb.CanServe() {
+		t.Fatal("expected the re-opened breaker to refuse traffic during its new cooldown")
+	}
+}
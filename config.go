@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const defaultRetries = 3
+
+// Duration wraps time.Duration so config values like "10ms" or "2s" can
+// be parsed directly from YAML.
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = 0
+		return nil
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Config is the top level shape of the -config file.
+type Config struct {
+	ListenAddr                   string       `yaml:"listen_addr"`
+	AdminAddr                    string       `yaml:"admin_addr"`
+	Strategy                     string       `yaml:"strategy"`
+	TransportMaxIdleConnsPerHost int          `yaml:"transport_max_idle_conns_per_host"`
+	TransportIdleConnTimeout     Duration     `yaml:"transport_idle_conn_timeout"`
+	Hosts                        []HostConfig `yaml:"hosts"`
+}
+
+// HostConfig routes requests whose Host header matches Host to Backends.
+// A Host of "*" is the catch-all pool used when no other entry matches.
+type HostConfig struct {
+	Host     string          `yaml:"host"`
+	Strategy string          `yaml:"strategy"`
+	Backends []BackendConfig `yaml:"backends"`
+}
+
+// BackendConfig describes a single backend and its retry, timeout and
+// health check behavior.
+type BackendConfig struct {
+	URL              string   `yaml:"url"`
+	Weight           int      `yaml:"weight"`
+	Retries          int      `yaml:"retries"`
+	Delay            Duration `yaml:"delay"`
+	Timeout          Duration `yaml:"timeout"`
+	HealthCheckPath  string   `yaml:"health_check_path"`
+	FailureThreshold int      `yaml:"failure_threshold"`
+	SuccessThreshold int      `yaml:"success_threshold"`
+	// InsecureSkipVerify disables TLS certificate verification for this
+	// backend only, for reaching self-signed dev/staging servers.
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+	// CircuitThreshold is the number of consecutive exhausted-retry
+	// failures before this backend's circuit breaker trips open.
+	CircuitThreshold int `yaml:"circuit_failure_threshold"`
+	// CircuitCooldown is how long the breaker stays open before
+	// allowing half-open probe requests through.
+	CircuitCooldown Duration `yaml:"circuit_cooldown"`
+	// CircuitHalfOpenMax caps how many probe requests a half-open
+	// breaker allows through before deciding whether to close again.
+	CircuitHalfOpenMax int `yaml:"circuit_half_open_max_probes"`
+}
+
+// LoadConfig reads and parses the load balancer configuration from path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing config: %w", err)
+	}
+	if len(cfg.Hosts) == 0 {
+		return nil, fmt.Errorf("config must declare at least one host")
+	}
+	if cfg.ListenAddr == "" {
+		cfg.ListenAddr = ":3030"
+	}
+	if cfg.AdminAddr == "" {
+		cfg.AdminAddr = ":9090"
+	}
+	return &cfg, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// probeTimeout bounds both the TCP dial and the HTTP GET portions of a
+// health check.
+const probeTimeout = 2 * time.Second
+
+// defaultPort returns the port to dial for a backend URL that doesn't
+// declare one explicitly, based on its scheme.
+func defaultPort(scheme string) string {
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// isBackendAlive checks whether a backend is reachable. It always
+// attempts a TCP dial, and — when the backend declares a
+// HealthCheckPath — additionally issues an HTTP GET against it,
+// treating anything outside the 2xx range as unhealthy. This catches
+// backends that accept TCP connections but answer with 5xx, which a
+// TCP-only probe would miss entirely.
+func isBackendAlive(b *Backend) bool {
+	addr := b.URL.Host
+	if b.URL.Port() == "" {
+		addr = net.JoinHostPort(b.URL.Hostname(), defaultPort(b.URL.Scheme))
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, probeTimeout)
+	if err != nil {
+		log.Println("Site unreachable, error: ", err)
+		return false
+	}
+	_ = conn.Close()
+
+	if b.HealthCheckPath == "" {
+		return true
+	}
+
+	healthURL := *b.URL
+	healthURL.Path = b.HealthCheckPath
+
+	// Reuse the backend's own transport so a probe of a self-signed
+	// HTTPS backend honors its configured InsecureSkipVerify instead of
+	// failing certificate verification every check.
+	client := http.Client{Timeout: probeTimeout, Transport: b.Transport}
+	resp, err := client.Get(healthURL.String())
+	if err != nil {
+		log.Println("Health check request failed, error: ", err)
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices
+}
@@ -2,14 +2,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"log"
-	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
-	"strings"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
@@ -55,131 +55,136 @@ func GetAttemptCount(ctx context.Context) (int, error) {
 func GetAttemptsFromContext(r *http.Request) int {
 	count, err := GetAttemptCount(r.Context())
 	if err != nil {
-		return count
+		// return 0 if there's no context value associated with key(Attempt)
+		return 0
 	}
-	return 1
+	return count
 }
 
 // GetRetryFromContext returns the retries for request
 func GetRetryFromContext(r *http.Request) int {
 	count, err := GetRetryCount(r.Context())
 	if err != nil {
-		return count
+		// return 0 if there's no context value associated with key(Retry)
+		return 0
 	}
-	// return 0 if there's no context value associated with key(Retry)
-	return 0
+	return count
 }
 
-// lb load balances the incoming request
-func lb(w http.ResponseWriter, r *http.Request) {
-	attempts := GetAttemptsFromContext(r)
-	if attempts > 3 {
-		log.Printf("%s(%s) Max attempts reached, terminating\n", r.RemoteAddr, r.URL.Path)
-		http.Error(w, "Service not available", http.StatusServiceUnavailable)
-		return
-	}
-
-	peer := serverPool.GetNextPeer()
-	if peer != nil {
-		// sends incoming request to next
-		peer.ReverseProxy.ServeHTTP(w, r)
-		return
-	}
-	http.Error(w, "Service not available", http.StatusServiceUnavailable)
-}
-
-// isAlive checks whether a backend is Alive by establishing a TCP connection
-func isBackendAlive(u *url.URL) bool {
-	timeout := 2 * time.Second
-	conn, err := net.DialTimeout("tcp", u.Host, timeout)
-	if err != nil {
-		log.Println("Site unreachable, error: ", err)
-		return false
-	}
-	_ = conn.Close()
-	return true
-}
-
-// healthCheck runs a routine for check status of the backends every 2 mins
-func healthCheck() {
-	t := time.NewTicker(time.Minute * 2)
+// healthCheck runs a routine that checks the status of every pool's
+// backends on the given interval, stopping cleanly when ctx is done.
+func healthCheck(ctx context.Context, router *Router, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
 	for {
 		select {
+		case <-ctx.Done():
+			return
 		case <-t.C:
 			log.Println("Starting health check...")
-			serverPool.HealthCheck()
+			for _, pool := range router.Pools() {
+				pool.HealthCheck()
+			}
 			log.Println("Health check completed")
 		}
 	}
 }
 
-var serverPool ServerPool
+// watchReload reloads the router's configuration whenever the process
+// receives SIGHUP, so backends can change without dropping connections.
+func watchReload(router *Router) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	for range sighup {
+		log.Println("Received SIGHUP, reloading configuration...")
+		if err := router.Reload(); err != nil {
+			log.Printf("Failed to reload configuration: %s\n", err)
+			continue
+		}
+		log.Println("Configuration reloaded")
+	}
+}
 
 func main() {
-	var serverList string
-	var port int
-	flag.StringVar(&serverList, "backends", "", "Load balanced backends, use commas to separate")
-	flag.IntVar(&port, "port", 3030, "Port to serve")
+	var configPath string
+	var healthCheckInterval time.Duration
+	var tlsCert, tlsKey string
+	var http2Enabled bool
+	var shutdownTimeout time.Duration
+	flag.StringVar(&configPath, "config", "", "Path to backend configuration file (YAML)")
+	flag.DurationVar(&healthCheckInterval, "healthcheck-interval", 2*time.Minute, "Interval between backend health checks")
+	flag.StringVar(&tlsCert, "tls-cert", "", "Path to a TLS certificate file; together with -tls-key, terminates TLS on the front-end listener")
+	flag.StringVar(&tlsKey, "tls-key", "", "Path to the TLS certificate's private key file")
+	flag.BoolVar(&http2Enabled, "http2", true, "Allow HTTP/2 on the front-end listener when TLS is enabled")
+	flag.DurationVar(&shutdownTimeout, "shutdown-timeout", 15*time.Second, "How long to let in-flight requests finish on SIGINT/SIGTERM before exiting")
 	flag.Parse()
 
-	if len(serverList) == 0 {
-		log.Fatal("Please provide one or more backends to load balance")
+	if configPath == "" {
+		log.Fatal("Please provide a -config file")
+	}
+	if (tlsCert == "") != (tlsKey == "") {
+		log.Fatal("-tls-cert and -tls-key must both be set to enable TLS")
 	}
 
-	// parse servers
-	servers := strings.Split(serverList, ",")
-	for _, server := range servers {
-		serverURL, err := url.Parse(server)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		// create proxy that sends incoming request to given server URL
-		proxy := httputil.NewSingleHostReverseProxy(serverURL)
-
-		proxy.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, e error) {
-			log.Printf("[%s] error: %s\n", serverURL.Host, e.Error())
-			retries := GetRetryFromContext(request)
-			if retries < 3 {
-				select {
-				// try after
-				case <-time.After(10 * time.Millisecond):
-					// increment Retry count.
-					ctx := SetRetryCount(request.Context(), retries+1)
-					proxy.ServeHTTP(writer, request.WithContext(ctx))
-				}
-				return
-			}
-
-			// after 3 retries, mark this backend as down
-			serverPool.MarkBackendStatus(serverURL, false)
-
-			// if the same request routing for few attempts with different backends, increase the count
-			attempts := GetAttemptsFromContext(request)
-			log.Printf("%s(%s) Attempting retry %d\n", request.RemoteAddr, request.URL.Path, attempts)
-			ctx := SetAttemptCount(request.Context(), attempts+1)
-			lb(writer, request.WithContext(ctx))
-		}
-
-		serverPool.AddBackend(&Backend{
-			URL:          serverURL,
-			Alive:        true,
-			ReverseProxy: proxy,
-		})
-		log.Printf("Configured server: %s\n", serverURL)
+	router, err := NewRouter(configPath)
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	// create http server
-	server := http.Server{
-		Addr:    fmt.Sprintf(":%d", port),
-		Handler: http.HandlerFunc(lb),
+	server := &http.Server{
+		Addr:    router.Addr(),
+		Handler: http.HandlerFunc(router.ServeHTTP),
+	}
+	if !http2Enabled {
+		// an empty, non-nil TLSNextProto opts the server out of the
+		// automatic HTTP/2 upgrade ListenAndServeTLS otherwise performs.
+		server.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
 	}
 
-	// start health checking
-	go healthCheck()
-
-	log.Printf("Load Balancer started at :%d\n", port)
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatal(err)
+	// serve Prometheus metrics and the admin API on a separate port
+	adminServer := &http.Server{
+		Addr:    router.AdminAddr(),
+		Handler: NewAdminHandler(router),
+	}
+	go func() {
+		log.Printf("Admin server started at %s\n", adminServer.Addr)
+		if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	// start health checking and config hot-reload
+	healthCtx, stopHealthCheck := context.WithCancel(context.Background())
+	go healthCheck(healthCtx, router, healthCheckInterval)
+	go watchReload(router)
+
+	go func() {
+		var err error
+		if tlsCert != "" {
+			log.Printf("Load Balancer started at %s (TLS)\n", router.Addr())
+			err = server.ListenAndServeTLS(tlsCert, tlsKey)
+		} else {
+			log.Printf("Load Balancer started at %s\n", router.Addr())
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			log.Fatal(err)
+		}
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	<-stop
+	log.Println("Shutting down...")
+	stopHealthCheck()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down load balancer: %s\n", err)
+	}
+	if err := adminServer.Shutdown(ctx); err != nil {
+		log.Printf("Error shutting down admin server: %s\n", err)
 	}
 }
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "lb_requests_total",
+		Help: "Total requests proxied to each backend, by response code.",
+	}, []string{"backend", "code"})
+
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "lb_request_duration_seconds",
+		Help: "Latency of requests proxied to each backend.",
+	}, []string{"backend"})
+
+	backendUp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lb_backend_up",
+		Help: "Whether a backend is currently considered healthy (1) or not (0).",
+	}, []string{"backend"})
+
+	retriesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lb_retries_total",
+		Help: "Total retries issued against the same backend after a proxy error.",
+	})
+
+	attemptsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "lb_attempts_total",
+		Help: "Total times a request was routed to a different backend after exhausting retries.",
+	})
+
+	circuitBreakerState = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lb_circuit_breaker_state",
+		Help: "Circuit breaker state per backend: 0=closed, 1=open, 2=half-open.",
+	}, []string{"backend"})
+)
+
+// boolToFloat converts a bool to the 1/0 a Prometheus gauge expects.
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// metricsTransport wraps a RoundTripper, recording the lb_requests_total
+// and lb_request_duration_seconds metrics for every request proxied to
+// backend, and gating each attempt on backend's circuit breaker.
+type metricsTransport struct {
+	backend *Backend
+	next    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	backendURL := t.backend.URL.String()
+	if !t.backend.breaker.Allow() {
+		requestsTotal.WithLabelValues(backendURL, "circuit_open").Inc()
+		return nil, errCircuitOpen
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	requestDuration.WithLabelValues(backendURL).Observe(time.Since(start).Seconds())
+
+	code := "error"
+	if resp != nil {
+		code = strconv.Itoa(resp.StatusCode)
+	}
+	// Only 2xx/3xx count as a breaker success; a 4xx/5xx response is a
+	// completed round trip (err == nil) but not evidence the backend is
+	// healthy, so it must not reset the consecutive-failure count.
+	if err == nil && resp.StatusCode < http.StatusBadRequest {
+		t.backend.breaker.RecordSuccess()
+	}
+	requestsTotal.WithLabelValues(backendURL, code).Inc()
+	return resp, err
+}
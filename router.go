@@ -0,0 +1,256 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Transport tuning shared by every backend unless overridden in the
+// -config file.
+const (
+	defaultMaxIdleConnsPerHost = 10
+	defaultIdleConnTimeout     = 90 * time.Second
+)
+
+// Router dispatches each request to the ServerPool for its Host header,
+// enabling virtual-host routing. It can be rebuilt in place via Reload,
+// which lets SIGHUP pick up config changes without dropping the
+// listener or in-flight requests.
+type Router struct {
+	configPath string
+
+	mu        sync.RWMutex
+	pools     map[string]*ServerPool
+	wildcard  *ServerPool
+	addr      string
+	adminAddr string
+}
+
+// NewRouter builds a Router from the config file at configPath.
+func NewRouter(configPath string) (*Router, error) {
+	rt := &Router{configPath: configPath}
+	if err := rt.Reload(); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// Reload re-reads the config file and swaps in freshly built pools.
+// Requests already being served by the old pools are unaffected.
+func (rt *Router) Reload() error {
+	cfg, err := LoadConfig(rt.configPath)
+	if err != nil {
+		return err
+	}
+
+	pools := make(map[string]*ServerPool, len(cfg.Hosts))
+	var wildcard *ServerPool
+	for _, hc := range cfg.Hosts {
+		pool, err := buildPool(cfg, hc)
+		if err != nil {
+			return err
+		}
+		if hc.Host == "*" {
+			wildcard = pool
+			continue
+		}
+		pools[hc.Host] = pool
+	}
+
+	rt.mu.Lock()
+	rt.pools = pools
+	rt.wildcard = wildcard
+	rt.addr = cfg.ListenAddr
+	rt.adminAddr = cfg.AdminAddr
+	rt.mu.Unlock()
+	return nil
+}
+
+// PoolFor returns the ServerPool that should handle a request for host,
+// falling back to the wildcard pool when host has no dedicated entry.
+func (rt *Router) PoolFor(host string) *ServerPool {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	if pool, ok := rt.pools[hostOnly(host)]; ok {
+		return pool
+	}
+	return rt.wildcard
+}
+
+// Addr is the address the front-end http.Server should listen on.
+func (rt *Router) Addr() string {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.addr
+}
+
+// AdminAddr is the address the admin/metrics http.Server should listen on.
+func (rt *Router) AdminAddr() string {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	return rt.adminAddr
+}
+
+// Pools returns every pool currently configured, for health checking.
+func (rt *Router) Pools() []*ServerPool {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	pools := make([]*ServerPool, 0, len(rt.pools)+1)
+	for _, pool := range rt.pools {
+		pools = append(pools, pool)
+	}
+	if rt.wildcard != nil {
+		pools = append(pools, rt.wildcard)
+	}
+	return pools
+}
+
+// ServeHTTP implements http.Handler by routing r to the pool for its
+// Host header.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	pool := rt.PoolFor(r.Host)
+	if pool == nil {
+		http.Error(w, "Service not available", http.StatusServiceUnavailable)
+		return
+	}
+	pool.lb(w, r)
+}
+
+// hostOnly strips a port from a Host header, if present.
+func hostOnly(host string) string {
+	h, _, err := net.SplitHostPort(host)
+	if err != nil {
+		return host
+	}
+	return h
+}
+
+// newBackendTransport builds the *http.Transport used to reach a
+// backend. MaxIdleConnsPerHost and IdleConnTimeout are shared across
+// every backend, tunable via the top-level config; InsecureSkipVerify
+// is set per-backend so self-signed dev certs can opt out of
+// verification without weakening every other backend.
+func newBackendTransport(cfg *Config, bc BackendConfig) *http.Transport {
+	maxIdle := cfg.TransportMaxIdleConnsPerHost
+	if maxIdle <= 0 {
+		maxIdle = defaultMaxIdleConnsPerHost
+	}
+	idleTimeout := time.Duration(cfg.TransportIdleConnTimeout)
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleConnTimeout
+	}
+
+	transport := &http.Transport{
+		MaxIdleConnsPerHost: maxIdle,
+		IdleConnTimeout:     idleTimeout,
+	}
+	if bc.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return transport
+}
+
+// buildPool constructs a ServerPool from a HostConfig, wiring each
+// backend's reverse proxy, retry behavior and error handling.
+func buildPool(cfg *Config, hc HostConfig) (*ServerPool, error) {
+	strategy := hc.Strategy
+	if strategy == "" {
+		strategy = cfg.Strategy
+	}
+
+	pool := &ServerPool{}
+	pool.SetBalancer(NewBalancer(strategy))
+
+	for _, bc := range hc.Backends {
+		serverURL, err := url.Parse(bc.URL)
+		if err != nil {
+			return nil, fmt.Errorf("host %q: %w", hc.Host, err)
+		}
+
+		weight := bc.Weight
+		if weight <= 0 {
+			weight = 1
+		}
+		retries := bc.Retries
+		if retries <= 0 {
+			retries = defaultRetries
+		}
+		// leave delay at 0 when unconfigured, so backoffSchedule falls back to
+		// defaultBackoff.Initial instead of always overriding it
+		delay := time.Duration(bc.Delay)
+		timeout := time.Duration(bc.Timeout)
+
+		transport := newBackendTransport(cfg, bc)
+		if timeout > 0 {
+			transport.ResponseHeaderTimeout = timeout
+		}
+
+		backend := &Backend{
+			URL:              serverURL,
+			Alive:            true,
+			Weight:           weight,
+			Retries:          retries,
+			Delay:            delay,
+			HealthCheckPath:  bc.HealthCheckPath,
+			FailureThreshold: bc.FailureThreshold,
+			SuccessThreshold: bc.SuccessThreshold,
+			Transport:        transport,
+			breaker:          newCircuitBreaker(serverURL.String(), bc.CircuitThreshold, time.Duration(bc.CircuitCooldown), bc.CircuitHalfOpenMax),
+		}
+
+		proxy := httputil.NewSingleHostReverseProxy(serverURL)
+		proxy.Transport = &metricsTransport{backend: backend, next: transport}
+		backend.ReverseProxy = proxy
+
+		proxy.ErrorHandler = func(writer http.ResponseWriter, request *http.Request, e error) {
+			log.Printf("[%s] error: %s\n", serverURL.Host, e.Error())
+			retries := GetRetryFromContext(request)
+			if retries < backend.Retries {
+				sleep, state, exceeded := backend.backoffSchedule().Next(GetBackoffState(request.Context()))
+				if !exceeded {
+					select {
+					// try after a jittered, growing delay
+					case <-time.After(sleep):
+						// increment Retry count and carry the backoff state forward.
+						retriesTotal.Inc()
+						ctx := SetRetryCount(request.Context(), retries+1)
+						ctx = SetBackoffState(ctx, state)
+						backend.Serve(writer, request.WithContext(ctx))
+					}
+					return
+				}
+				log.Printf("[%s] backoff budget exhausted after %s\n", serverURL.Host, state.elapsed)
+			}
+
+			// after Retries attempts (or the backoff budget is exhausted), feed the failure into
+			// this backend's circuit breaker; the breaker (not a hard Alive flip) now governs
+			// whether the backend stays in rotation, so circuit_failure_threshold consecutive
+			// exhausted requests are required to actually pull it out
+			backend.breaker.Trip()
+
+			// if the same request routing for few attempts with different backends, increase the count
+			// and reset the retry count and backoff state so the next backend gets its own
+			// configured retry budget, not what's left of this backend's
+			attemptsTotal.Inc()
+			attempts := GetAttemptsFromContext(request)
+			log.Printf("%s(%s) Attempting retry %d\n", request.RemoteAddr, request.URL.Path, attempts)
+			ctx := SetAttemptCount(request.Context(), attempts+1)
+			ctx = SetRetryCount(ctx, 0)
+			ctx = SetBackoffState(ctx, backoffState{})
+			pool.lb(writer, request.WithContext(ctx))
+		}
+
+		pool.AddBackend(backend)
+		backendUp.WithLabelValues(serverURL.String()).Set(1)
+		log.Printf("Configured server: %s (host=%s, weight=%d)\n", serverURL, hc.Host, weight)
+	}
+
+	return pool, nil
+}
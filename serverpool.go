@@ -0,0 +1,86 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// ServerPool holds information about reachable backends and the
+// Balancer used to pick between them.
+type ServerPool struct {
+	backends []*Backend
+	balancer Balancer
+}
+
+// SetBalancer sets the strategy GetNextPeer uses to pick a backend. When
+// never called, the pool defaults to round robin.
+func (s *ServerPool) SetBalancer(b Balancer) {
+	s.balancer = b
+}
+
+// AddBackend to the server pool
+func (s *ServerPool) AddBackend(backend *Backend) {
+	s.backends = append(s.backends, backend)
+}
+
+// MarkBackendStatus changes a status of a backend
+func (s *ServerPool) MarkBackendStatus(backendUrl *url.URL, alive bool) {
+	for _, b := range s.backends {
+		if b.URL.String() == backendUrl.String() {
+			b.SetAlive(alive)
+			backendUp.WithLabelValues(b.URL.String()).Set(boolToFloat(alive))
+			break
+		}
+	}
+}
+
+// GetNextPeer returns the next active peer to take r, as chosen by the
+// pool's Balancer
+func (s *ServerPool) GetNextPeer(r *http.Request) *Backend {
+	if s.balancer == nil {
+		s.balancer = &RoundRobinBalancer{}
+	}
+	return s.balancer.Next(s.backends, r)
+}
+
+// maxAttempts bounds how many different backends a request may be
+// routed to within this pool before giving up.
+func (s *ServerPool) maxAttempts() int {
+	if len(s.backends) == 0 {
+		return 1
+	}
+	return len(s.backends)
+}
+
+// lb load balances an incoming request across this pool's backends.
+func (s *ServerPool) lb(w http.ResponseWriter, r *http.Request) {
+	attempts := GetAttemptsFromContext(r)
+	if attempts > s.maxAttempts() {
+		log.Printf("%s(%s) Max attempts reached, terminating\n", r.RemoteAddr, r.URL.Path)
+		http.Error(w, "Service not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	peer := s.GetNextPeer(r)
+	if peer != nil {
+		// sends incoming request to next
+		peer.Serve(w, r)
+		return
+	}
+	http.Error(w, "Service not available", http.StatusServiceUnavailable)
+}
+
+// HealthCheck pings the backends and update the status
+func (s *ServerPool) HealthCheck() {
+	for _, b := range s.backends {
+		reachable := isBackendAlive(b)
+		alive := b.RecordCheck(reachable)
+		s.MarkBackendStatus(b.URL, alive)
+		status := "up"
+		if !alive {
+			status = "down"
+		}
+		log.Printf("%s [%s]\n", b.URL, status)
+	}
+}